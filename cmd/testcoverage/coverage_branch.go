@@ -0,0 +1,482 @@
+package testcoverage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	checktest "github.com/openfga/openfga/internal/test/check"
+)
+
+// rewriteNodeType identifies the kind of a single node in a relation's rewrite tree.
+type rewriteNodeType string
+
+const (
+	rewriteNodeThis            rewriteNodeType = "this"
+	rewriteNodeComputedUserset rewriteNodeType = "computed_userset"
+	rewriteNodeTupleToUserset  rewriteNodeType = "tuple_to_userset"
+	rewriteNodeUnion           rewriteNodeType = "union"
+	rewriteNodeIntersection    rewriteNodeType = "intersection"
+	rewriteNodeDifference      rewriteNodeType = "difference"
+)
+
+// differenceRole distinguishes a Difference node's two children in BranchCoverage output: the
+// "base" arm grants access, the "subtract" arm revokes it (BUT NOT).
+type differenceRole string
+
+const (
+	differenceRoleBase     differenceRole = "base"
+	differenceRoleSubtract differenceRole = "subtract"
+)
+
+// RewriteNode is one node of a relation's rewrite tree (This / ComputedUserset / TupleToUserset
+// / Union / Intersection / Difference), identified by a stable, human-readable ID such as
+// `document#viewer.union[1]`.
+type RewriteNode struct {
+	ID       string          `json:"id"`
+	Type     rewriteNodeType `json:"type"`
+	Relation string          `json:"relation,omitempty"` // target relation for computed_userset/tuple_to_userset
+	Tupleset string          `json:"tupleset,omitempty"` // tupleset relation for tuple_to_userset
+	Role     differenceRole  `json:"role,omitempty"`     // this node's position when its parent is a Difference
+	Children []*RewriteNode  `json:"children,omitempty"`
+}
+
+// BranchEntry reports whether a single rewrite-tree node was actually traversed while
+// resolving any assertion.
+type BranchEntry struct {
+	TypeName     string          `json:"type"`
+	RelationName string          `json:"relation"`
+	NodeID       string          `json:"node_id"`
+	NodeType     rewriteNodeType `json:"node_type"`
+	Role         differenceRole  `json:"role,omitempty"`
+	Covered      bool            `json:"covered"`
+
+	// ConditionUnverified is true when this node was only reached through a tuple carrying an
+	// ABAC condition. The tracer has no CEL evaluator, so it cannot tell whether that condition
+	// would actually evaluate true against an assertion's Context; reporting Covered: true here
+	// would overstate coverage on ABAC models, so such nodes are surfaced separately instead.
+	ConditionUnverified bool `json:"condition_unverified,omitempty"`
+}
+
+// BranchCoverage reports, per relation, which rewrite-tree branches (OR/AND/BUT-NOT arms) were
+// actually exercised by an assertion's resolution, analogous to branch coverage in `go test
+// -cover`.
+type BranchCoverage struct {
+	UncoveredBranches []BranchEntry `json:"uncovered_branches"`
+	CoveredBranches   []BranchEntry `json:"covered_branches"`
+}
+
+// buildRewriteTrees builds one RewriteNode tree per (type, relation) in the model, keyed by
+// "type#relation".
+func buildRewriteTrees(model *openfgav1.AuthorizationModel) map[string]*RewriteNode {
+	trees := make(map[string]*RewriteNode)
+
+	for _, typeDef := range model.GetTypeDefinitions() {
+		typeName := typeDef.GetType()
+		for relationName, rewrite := range typeDef.GetRelations() {
+			key := fmt.Sprintf("%s#%s", typeName, relationName)
+			trees[key] = buildRewriteNode(key, rewrite)
+		}
+	}
+
+	return trees
+}
+
+func buildRewriteNode(id string, rewrite *openfgav1.Userset) *RewriteNode {
+	if rewrite == nil {
+		return nil
+	}
+
+	switch r := rewrite.Userset.(type) {
+	case *openfgav1.Userset_This:
+		return &RewriteNode{ID: id, Type: rewriteNodeThis}
+	case *openfgav1.Userset_ComputedUserset:
+		return &RewriteNode{ID: id, Type: rewriteNodeComputedUserset, Relation: r.ComputedUserset.GetRelation()}
+	case *openfgav1.Userset_TupleToUserset:
+		return &RewriteNode{
+			ID:       id,
+			Type:     rewriteNodeTupleToUserset,
+			Relation: r.TupleToUserset.GetComputedUserset().GetRelation(),
+			Tupleset: r.TupleToUserset.GetTupleset().GetRelation(),
+		}
+	case *openfgav1.Userset_Union:
+		node := &RewriteNode{ID: id, Type: rewriteNodeUnion}
+		for i, child := range r.Union.GetChild() {
+			node.Children = append(node.Children, buildRewriteNode(fmt.Sprintf("%s.union[%d]", id, i), child))
+		}
+		return node
+	case *openfgav1.Userset_Intersection:
+		node := &RewriteNode{ID: id, Type: rewriteNodeIntersection}
+		for i, child := range r.Intersection.GetChild() {
+			node.Children = append(node.Children, buildRewriteNode(fmt.Sprintf("%s.intersection[%d]", id, i), child))
+		}
+		return node
+	case *openfgav1.Userset_Difference:
+		node := &RewriteNode{ID: id, Type: rewriteNodeDifference}
+		base := buildRewriteNode(id+".difference.base", r.Difference.GetBase())
+		if base != nil {
+			base.Role = differenceRoleBase
+		}
+		subtract := buildRewriteNode(id+".difference.subtract", r.Difference.GetSubtract())
+		if subtract != nil {
+			subtract.Role = differenceRoleSubtract
+		}
+		node.Children = []*RewriteNode{base, subtract}
+		return node
+	default:
+		return nil
+	}
+}
+
+// tupleIndex is a minimal in-memory tuple store used to trace which rewrite-tree branches an
+// assertion's resolution enters, keyed by "object#relation". It keeps the full TupleKey (rather
+// than just the user string) so the tracer can tell whether a candidate tuple carries an ABAC
+// condition.
+type tupleIndex map[string][]*openfgav1.TupleKey
+
+func buildTupleIndex(tuples []*openfgav1.TupleKey) tupleIndex {
+	idx := make(tupleIndex)
+	for _, t := range tuples {
+		if t == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s#%s", t.GetObject(), t.GetRelation())
+		idx[key] = append(idx[key], t)
+	}
+	return idx
+}
+
+func (idx tupleIndex) directTuples(object, relation string) []*openfgav1.TupleKey {
+	return idx[fmt.Sprintf("%s#%s", object, relation)]
+}
+
+// branchTracer evaluates assertions against a model's rewrite trees, recording which nodes are
+// actually traversed. It deliberately reimplements only as much of Check resolution as is
+// needed to trace branches (This/ComputedUserset/TupleToUserset/Union/Intersection/Difference),
+// short-circuiting the same way the real resolver does: Union stops at the first truthy child,
+// Intersection stops at the first falsy one, and Difference only evaluates its subtract branch
+// when the base branch is true.
+//
+// This package is a standalone CLI tool that only reads a static model/test-fixture file pair; it
+// has no datastore, so it cannot invoke pkg/server/commands/check (or the internal resolver)
+// directly. The tracer is a best-effort approximation rather than ground truth: it has no CEL
+// evaluator, so a This node resolved through a tuple carrying an ABAC condition is recorded as
+// ConditionUnverified rather than Covered (see BranchEntry), and it ignores the per-stage Model
+// override in TestFile (it traces every stage against the single model passed to traceModel).
+// Treat "covered" here as "reached, and not dependent on an unverified condition", not as
+// "resolved the same way Check would".
+type branchTracer struct {
+	trees          map[string]*RewriteNode
+	covered        map[string]bool
+	conditionGated map[string]bool
+}
+
+func newBranchTracer(trees map[string]*RewriteNode) *branchTracer {
+	return &branchTracer{
+		trees:          trees,
+		covered:        make(map[string]bool),
+		conditionGated: make(map[string]bool),
+	}
+}
+
+// trace resolves whether `user` has `relation` on `object`, recording every rewrite-tree node
+// it traverses along the way.
+func (bt *branchTracer) trace(typeName, relationName, object, user string, tuples tupleIndex, visiting map[string]bool) bool {
+	key := fmt.Sprintf("%s#%s", typeName, relationName)
+	node, exists := bt.trees[key]
+	if !exists || node == nil {
+		return false
+	}
+
+	// Guard against cycles between relations (e.g. recursive usersets) that would otherwise
+	// cause infinite recursion while tracing.
+	guardKey := fmt.Sprintf("%s@%s:%s", key, object, user)
+	if visiting[guardKey] {
+		return false
+	}
+	visiting[guardKey] = true
+	defer delete(visiting, guardKey)
+
+	matched, _ := bt.traceNode(node, typeName, relationName, object, user, tuples, visiting)
+	return matched
+}
+
+// traceNode traverses a single rewrite-tree node, returning whether it matched and whether that
+// result depended on a tuple carrying an unverified ABAC condition.
+func (bt *branchTracer) traceNode(node *RewriteNode, typeName, relationName, object, user string, tuples tupleIndex, visiting map[string]bool) (matched, conditionGated bool) {
+	if node == nil {
+		return false, false
+	}
+	bt.covered[node.ID] = true
+
+	switch node.Type {
+	case rewriteNodeThis:
+		matched, conditionGated = bt.traceThis(object, relationName, user, tuples, visiting)
+	case rewriteNodeComputedUserset:
+		matched = bt.trace(typeName, node.Relation, object, user, tuples, visiting)
+	case rewriteNodeTupleToUserset:
+		matched, conditionGated = bt.traceTupleToUserset(node, object, user, tuples, visiting)
+	case rewriteNodeUnion:
+		for _, child := range node.Children {
+			m, g := bt.traceNode(child, typeName, relationName, object, user, tuples, visiting)
+			conditionGated = conditionGated || g
+			if m {
+				matched = true
+				break
+			}
+		}
+	case rewriteNodeIntersection:
+		matched = true
+		for _, child := range node.Children {
+			m, g := bt.traceNode(child, typeName, relationName, object, user, tuples, visiting)
+			conditionGated = conditionGated || g
+			if !m {
+				matched = false
+				break
+			}
+		}
+	case rewriteNodeDifference:
+		base, subtract := node.Children[0], node.Children[1]
+		baseMatched, baseGated := bt.traceNode(base, typeName, relationName, object, user, tuples, visiting)
+		conditionGated = baseGated
+		if !baseMatched {
+			break
+		}
+		subtractMatched, subtractGated := bt.traceNode(subtract, typeName, relationName, object, user, tuples, visiting)
+		conditionGated = conditionGated || subtractGated
+		matched = !subtractMatched
+	}
+
+	// A node stays condition-gated only while every traversal of it was gated; a single ungated
+	// traversal clears it (stored as false) for good.
+	if conditionGated {
+		if _, seen := bt.conditionGated[node.ID]; !seen {
+			bt.conditionGated[node.ID] = true
+		}
+	} else {
+		bt.conditionGated[node.ID] = false
+	}
+
+	return matched, conditionGated
+}
+
+// traceTupleToUserset resolves a TupleToUserset node: for every direct tupleset tuple, it
+// recurses into the computed userset relation on the tupleset tuple's target object.
+func (bt *branchTracer) traceTupleToUserset(node *RewriteNode, object, user string, tuples tupleIndex, visiting map[string]bool) (matched, conditionGated bool) {
+	// The tupleset side of a TupleToUserset is always a direct (This) relation per the OpenFGA
+	// model spec, so reading only direct tuples here matches the real resolver.
+	for _, tupleset := range tuples.directTuples(object, node.Tupleset) {
+		targetType, targetID, err := splitObject(tupleset.GetUser())
+		if err != nil {
+			continue
+		}
+		if bt.trace(targetType, node.Relation, fmt.Sprintf("%s:%s", targetType, targetID), user, tuples, visiting) {
+			matched = true
+			// Only the tupleset tuple that produced the match can gate it; keep looking for an
+			// unconditioned one if this one carries a condition.
+			conditionGated = tupleset.GetCondition().GetName() != ""
+			if !conditionGated {
+				break
+			}
+		}
+	}
+
+	return matched, conditionGated
+}
+
+// traceThis resolves a `This` node: a direct tuple to `user`, a direct `<type>:*` wildcard
+// tuple matching user's type, or a direct tuple to a userset subject that itself grants `user`.
+// A match that only comes from tuples carrying an ABAC condition is recorded as conditionGated,
+// since the tracer has no CEL evaluator to confirm the condition would actually hold.
+func (bt *branchTracer) traceThis(object, relation, user string, tuples tupleIndex, visiting map[string]bool) (matched, conditionGated bool) {
+	subjectType, err := getObjectType(user)
+	if err != nil {
+		subjectType = ""
+	}
+
+	matchedUnconditioned := false
+	for _, candidate := range tuples.directTuples(object, relation) {
+		candidateUser := candidate.GetUser()
+		candidateMatched := candidateUser == user || candidateUser == fmt.Sprintf("%s:*", subjectType)
+		if !candidateMatched {
+			if usersetType, usersetID, usersetRelation, ok := splitUserset(candidateUser); ok {
+				candidateMatched = bt.trace(usersetType, usersetRelation, fmt.Sprintf("%s:%s", usersetType, usersetID), user, tuples, visiting)
+			}
+		}
+		if !candidateMatched {
+			continue
+		}
+		matched = true
+		if candidate.GetCondition().GetName() == "" {
+			matchedUnconditioned = true
+		}
+	}
+
+	return matched, matched && !matchedUnconditioned
+}
+
+// splitObject splits "type:id" into its parts.
+func splitObject(object string) (typeName, id string, err error) {
+	idx := strings.IndexByte(object, ':')
+	if idx < 0 {
+		return "", "", fmt.Errorf("invalid object format: %s", object)
+	}
+	return object[:idx], object[idx+1:], nil
+}
+
+// splitUserset splits a subject of the form "type:id#relation" into its parts.
+func splitUserset(subject string) (typeName, id, relation string, ok bool) {
+	hashIdx := strings.IndexByte(subject, '#')
+	if hashIdx < 0 {
+		return "", "", "", false
+	}
+	colonIdx := strings.IndexByte(subject[:hashIdx], ':')
+	if colonIdx < 0 {
+		return "", "", "", false
+	}
+	return subject[:colonIdx], subject[colonIdx+1 : hashIdx], subject[hashIdx+1:], true
+}
+
+// traceModel runs the branch tracer over every CheckAssertion in testData and returns the
+// model's rewrite trees alongside the resulting tracer state. Both BranchCoverage reporting
+// (analyzeBranchCoverage) and indirect-testing propagation (buildCoveredDependencyGraph) are
+// derived from this single trace, so they agree on exactly which branches were exercised.
+func traceModel(model *openfgav1.AuthorizationModel, testData *TestFile) (map[string]*RewriteNode, *branchTracer) {
+	trees := buildRewriteTrees(model)
+	tracer := newBranchTracer(trees)
+
+	for _, test := range testData.Tests {
+		for _, stage := range test.Stages {
+			seedTuples := buildTupleIndex(stage.Tuples)
+			for _, assertion := range stage.CheckAssertions {
+				traceCheckAssertion(tracer, seedTuples, assertion)
+			}
+		}
+	}
+
+	return trees, tracer
+}
+
+func traceCheckAssertion(tracer *branchTracer, seedTuples tupleIndex, assertion *checktest.Assertion) {
+	if assertion == nil || assertion.Tuple == nil {
+		return
+	}
+
+	objectType, err := getObjectType(assertion.Tuple.GetObject())
+	if err != nil {
+		return
+	}
+
+	// Contextual tuples are scoped to this assertion only, so layer them over the stage's
+	// seed tuples rather than mutating the shared index.
+	tuples := make(tupleIndex, len(seedTuples))
+	for k, v := range seedTuples {
+		tuples[k] = v
+	}
+	for k, v := range buildTupleIndex(assertion.ContextualTuples) {
+		tuples[k] = append(tuples[k], v...)
+	}
+
+	tracer.trace(objectType, assertion.Tuple.GetRelation(), assertion.Tuple.GetObject(), assertion.Tuple.GetUser(), tuples, make(map[string]bool))
+}
+
+// analyzeBranchCoverage renders a BranchCoverage report from an already-traced model (see
+// traceModel). Nodes reached only through a tuple carrying an unverified ABAC condition are
+// reported as ConditionUnverified rather than Covered.
+func analyzeBranchCoverage(model *openfgav1.AuthorizationModel, trees map[string]*RewriteNode, tracer *branchTracer) BranchCoverage {
+	report := BranchCoverage{
+		UncoveredBranches: []BranchEntry{},
+		CoveredBranches:   []BranchEntry{},
+	}
+
+	for _, typeDef := range model.GetTypeDefinitions() {
+		typeName := typeDef.GetType()
+
+		relationNames := make([]string, 0, len(typeDef.GetRelations()))
+		for relationName := range typeDef.GetRelations() {
+			relationNames = append(relationNames, relationName)
+		}
+		sort.Strings(relationNames)
+
+		for _, relationName := range relationNames {
+			key := fmt.Sprintf("%s#%s", typeName, relationName)
+			collectBranchEntries(&report, tracer, typeName, relationName, trees[key])
+		}
+	}
+
+	sortBranchEntries(report.CoveredBranches)
+	sortBranchEntries(report.UncoveredBranches)
+
+	return report
+}
+
+// sortBranchEntries sorts a BranchCoverage bucket in place by (type, relation, node ID), so
+// JSON/YAML output is reproducible across runs.
+func sortBranchEntries(entries []BranchEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].TypeName != entries[j].TypeName {
+			return entries[i].TypeName < entries[j].TypeName
+		}
+		if entries[i].RelationName != entries[j].RelationName {
+			return entries[i].RelationName < entries[j].RelationName
+		}
+		return entries[i].NodeID < entries[j].NodeID
+	})
+}
+
+func collectBranchEntries(report *BranchCoverage, tracer *branchTracer, typeName, relationName string, node *RewriteNode) {
+	if node == nil {
+		return
+	}
+
+	entry := BranchEntry{
+		TypeName:            typeName,
+		RelationName:        relationName,
+		NodeID:              node.ID,
+		NodeType:            node.Type,
+		Role:                node.Role,
+		Covered:             tracer.covered[node.ID] && !tracer.conditionGated[node.ID],
+		ConditionUnverified: tracer.conditionGated[node.ID],
+	}
+	if entry.Covered {
+		report.CoveredBranches = append(report.CoveredBranches, entry)
+	} else {
+		report.UncoveredBranches = append(report.UncoveredBranches, entry)
+	}
+
+	for _, child := range node.Children {
+		collectBranchEntries(report, tracer, typeName, relationName, child)
+	}
+}
+
+// buildCoveredDependencyGraph derives a relation dependency graph from the ComputedUserset and
+// TupleToUserset rewrite-tree edges that were actually traversed while tracing assertions, so
+// indirect-testing propagation (see markIndirectlyTested) only credits a relation that a covered
+// branch actually reached, instead of every relation the rewrite could structurally reach.
+func buildCoveredDependencyGraph(trees map[string]*RewriteNode, tracer *branchTracer) map[string][]string {
+	graph := make(map[string][]string)
+
+	for key, node := range trees {
+		typeName, _ := splitRelationKey(key)
+		collectCoveredDependencies(typeName, key, node, tracer, graph)
+	}
+
+	return graph
+}
+
+func collectCoveredDependencies(typeName, sourceKey string, node *RewriteNode, tracer *branchTracer, graph map[string][]string) {
+	if node == nil || !tracer.covered[node.ID] {
+		return
+	}
+
+	switch node.Type {
+	case rewriteNodeComputedUserset, rewriteNodeTupleToUserset:
+		graph[sourceKey] = append(graph[sourceKey], fmt.Sprintf("%s#%s", typeName, node.Relation))
+	}
+
+	for _, child := range node.Children {
+		collectCoveredDependencies(typeName, sourceKey, child, tracer, graph)
+	}
+}
@@ -0,0 +1,258 @@
+package testcoverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	parser "github.com/openfga/language/pkg/go/transformer"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	baselineFlag = "baseline"
+
+	formatMarkdown = "markdown"
+
+	statusUntested = "untested"
+	statusPartial  = "partial"
+	statusFull     = "full"
+)
+
+// NewTestCoverageDiffCommand returns the `test-coverage diff` subcommand, which compares the
+// current coverage run against a previously emitted baseline report so CI can enforce "no
+// regression in model coverage" without requiring 100% coverage upfront.
+func NewTestCoverageDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare test coverage against a baseline report",
+		Long: `Compare the current test coverage run against a baseline CoverageReport (as
+emitted by "openfga test-coverage --format=json") and print per-relation deltas: newly
+untested relations (regressions), newly covered relations, and relations whose
+partial/full status changed.`,
+		RunE: runTestCoverageDiff,
+		Args: cobra.NoArgs,
+	}
+
+	flags := cmd.Flags()
+	flags.String(modelFileFlag, "", "path to the model file (DSL format)")
+	flags.String(testFileFlag, "", "path to the test file (YAML format)")
+	flags.String(baselineFlag, "", "path to a previously emitted coverage report (JSON) to diff against")
+	flags.String(formatFlag, formatJSON, "output format, one of: json, markdown")
+
+	_ = cmd.MarkFlagRequired(modelFileFlag)
+	_ = cmd.MarkFlagRequired(testFileFlag)
+	_ = cmd.MarkFlagRequired(baselineFlag)
+
+	return cmd
+}
+
+// RelationDelta describes how a single relation's coverage status changed between a baseline
+// report and the current run.
+type RelationDelta struct {
+	TypeName       string `json:"type"`
+	RelationName   string `json:"relation"`
+	BaselineStatus string `json:"baseline_status"`
+	CurrentStatus  string `json:"current_status"`
+}
+
+// CoverageDiff reports the per-relation deltas between a baseline CoverageReport and a current
+// one.
+type CoverageDiff struct {
+	// Regressions lists relations that were tested (partially or fully) in the baseline but
+	// are untested in the current run.
+	Regressions []RelationDelta `json:"regressions"`
+
+	// NewlyCovered lists relations that were untested in the baseline but are now tested.
+	NewlyCovered []RelationDelta `json:"newly_covered"`
+
+	// StatusChanged lists relations that were tested in both the baseline and the current
+	// run, but whose partial/full status changed.
+	StatusChanged []RelationDelta `json:"status_changed"`
+}
+
+func runTestCoverageDiff(cmd *cobra.Command, _ []string) error {
+	modelFile, _ := cmd.Flags().GetString(modelFileFlag)
+	testFile, _ := cmd.Flags().GetString(testFileFlag)
+	baselinePath, _ := cmd.Flags().GetString(baselineFlag)
+	format, _ := cmd.Flags().GetString(formatFlag)
+
+	modelContent, err := os.ReadFile(modelFile)
+	if err != nil {
+		return fmt.Errorf("failed to read model file: %w", err)
+	}
+
+	model, err := parser.TransformDSLToProto(string(modelContent))
+	if err != nil {
+		return fmt.Errorf("failed to parse model: %w", err)
+	}
+
+	testContent, err := os.ReadFile(testFile)
+	if err != nil {
+		return fmt.Errorf("failed to read test file: %w", err)
+	}
+
+	var testData TestFile
+	if err := yaml.Unmarshal(testContent, &testData); err != nil {
+		return fmt.Errorf("failed to parse test file: %w", err)
+	}
+
+	current, err := analyzeCoverage(model, &testData)
+	if err != nil {
+		return fmt.Errorf("failed to analyze coverage: %w", err)
+	}
+
+	baselineContent, err := os.ReadFile(baselinePath)
+	if err != nil {
+		return fmt.Errorf("failed to read baseline report: %w", err)
+	}
+
+	var baseline CoverageReport
+	if err := json.Unmarshal(baselineContent, &baseline); err != nil {
+		return fmt.Errorf("failed to parse baseline report: %w", err)
+	}
+
+	diff := compareCoverage(&baseline, current)
+
+	rendered, err := renderDiff(diff, format)
+	if err != nil {
+		return fmt.Errorf("error generating diff: %w", err)
+	}
+	fmt.Println(rendered)
+
+	if len(diff.Regressions) > 0 {
+		return fmt.Errorf("%d relation(s) regressed to untested", len(diff.Regressions))
+	}
+
+	return nil
+}
+
+// relationStatuses builds a map of "type#relation" -> status ("untested", "partial", "full")
+// from a CoverageReport's buckets.
+func relationStatuses(report *CoverageReport) map[string]string {
+	statuses := make(map[string]string)
+	for _, rel := range report.UntestedRelations {
+		statuses[fmt.Sprintf("%s#%s", rel.TypeName, rel.RelationName)] = statusUntested
+	}
+	for _, rel := range report.PartiallyTested {
+		statuses[fmt.Sprintf("%s#%s", rel.TypeName, rel.RelationName)] = statusPartial
+	}
+	for _, rel := range report.FullyTested {
+		statuses[fmt.Sprintf("%s#%s", rel.TypeName, rel.RelationName)] = statusFull
+	}
+	return statuses
+}
+
+// compareCoverage diffs a baseline CoverageReport against a current one, reporting regressions,
+// newly covered relations, and relations whose tested/partial/full status changed.
+func compareCoverage(baseline, current *CoverageReport) *CoverageDiff {
+	baselineStatuses := relationStatuses(baseline)
+	currentStatuses := relationStatuses(current)
+
+	diff := &CoverageDiff{
+		Regressions:   []RelationDelta{},
+		NewlyCovered:  []RelationDelta{},
+		StatusChanged: []RelationDelta{},
+	}
+
+	for key, currentStatus := range currentStatuses {
+		baselineStatus, existedInBaseline := baselineStatuses[key]
+		if !existedInBaseline {
+			continue
+		}
+
+		if baselineStatus == currentStatus {
+			continue
+		}
+
+		typeName, relationName := splitRelationKey(key)
+		delta := RelationDelta{
+			TypeName:       typeName,
+			RelationName:   relationName,
+			BaselineStatus: baselineStatus,
+			CurrentStatus:  currentStatus,
+		}
+
+		switch {
+		case baselineStatus != statusUntested && currentStatus == statusUntested:
+			diff.Regressions = append(diff.Regressions, delta)
+		case baselineStatus == statusUntested && currentStatus != statusUntested:
+			diff.NewlyCovered = append(diff.NewlyCovered, delta)
+		default:
+			diff.StatusChanged = append(diff.StatusChanged, delta)
+		}
+	}
+
+	// currentStatuses is a map, so the loop above visits keys in random order regardless of
+	// whether the baseline/current reports themselves are sorted; sort each bucket explicitly so
+	// the markdown table and any committed diff output are reproducible across runs.
+	sortRelationDeltas(diff.Regressions)
+	sortRelationDeltas(diff.NewlyCovered)
+	sortRelationDeltas(diff.StatusChanged)
+
+	return diff
+}
+
+// sortRelationDeltas sorts a CoverageDiff bucket in place by (type, relation).
+func sortRelationDeltas(deltas []RelationDelta) {
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].TypeName != deltas[j].TypeName {
+			return deltas[i].TypeName < deltas[j].TypeName
+		}
+		return deltas[i].RelationName < deltas[j].RelationName
+	})
+}
+
+func splitRelationKey(key string) (typeName, relationName string) {
+	parts := strings.SplitN(key, "#", 2)
+	if len(parts) != 2 {
+		return key, ""
+	}
+	return parts[0], parts[1]
+}
+
+func renderDiff(diff *CoverageDiff, format string) (string, error) {
+	switch format {
+	case "", formatJSON:
+		marshalled, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(marshalled), nil
+	case formatMarkdown:
+		return renderDiffMarkdown(diff), nil
+	default:
+		return "", fmt.Errorf("unsupported --%s value %q: must be one of %q, %q", formatFlag, format, formatJSON, formatMarkdown)
+	}
+}
+
+// renderDiffMarkdown renders a diff as a markdown table suitable for pasting into a PR comment.
+func renderDiffMarkdown(diff *CoverageDiff) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Model Coverage Diff\n\n")
+	writeDiffMarkdownSection(&sb, "Regressions", diff.Regressions)
+	writeDiffMarkdownSection(&sb, "Newly Covered", diff.NewlyCovered)
+	writeDiffMarkdownSection(&sb, "Status Changed", diff.StatusChanged)
+
+	return sb.String()
+}
+
+func writeDiffMarkdownSection(sb *strings.Builder, title string, deltas []RelationDelta) {
+	fmt.Fprintf(sb, "### %s (%d)\n\n", title, len(deltas))
+	if len(deltas) == 0 {
+		sb.WriteString("_none_\n\n")
+		return
+	}
+
+	sb.WriteString("| Type | Relation | Baseline | Current |\n")
+	sb.WriteString("| --- | --- | --- | --- |\n")
+	for _, delta := range deltas {
+		fmt.Fprintf(sb, "| %s | %s | %s | %s |\n", delta.TypeName, delta.RelationName, delta.BaselineStatus, delta.CurrentStatus)
+	}
+	sb.WriteString("\n")
+}
@@ -0,0 +1,166 @@
+package testcoverage
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// renderReport marshals a CoverageReport in the requested output format.
+func renderReport(report *CoverageReport, format string) (string, error) {
+	switch format {
+	case "", formatJSON:
+		marshalled, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(marshalled), nil
+	case formatYAML:
+		marshalled, err := yaml.Marshal(report)
+		if err != nil {
+			return "", err
+		}
+		return string(marshalled), nil
+	case formatHTML:
+		return renderReportHTML(report), nil
+	case formatCobertura:
+		return renderReportCobertura(report)
+	default:
+		return "", fmt.Errorf("unsupported --%s value %q: must be one of %q, %q, %q, %q", formatFlag, format, formatJSON, formatYAML, formatHTML, formatCobertura)
+	}
+}
+
+// renderReportHTML renders a simple, dependency-free HTML report suitable for publishing as a
+// CI artifact.
+func renderReportHTML(report *CoverageReport) string {
+	var sb strings.Builder
+
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head><title>OpenFGA Model Coverage Report</title></head>\n<body>\n")
+	fmt.Fprintf(&sb, "<h1>OpenFGA Model Coverage Report</h1>\n")
+	fmt.Fprintf(&sb, "<p>Coverage: %.2f%% (weighted: %.2f%%) across %d relation(s)</p>\n",
+		report.Summary.CoveragePercentage, report.Summary.WeightedCoveragePercentage, report.Summary.TotalRelations)
+
+	renderHTMLSection(&sb, "Untested Relations", report.UntestedRelations)
+	renderHTMLSection(&sb, "Partially Tested Relations", report.PartiallyTested)
+	renderHTMLSection(&sb, "Fully Tested Relations", report.FullyTested)
+
+	sb.WriteString("</body>\n</html>\n")
+
+	return sb.String()
+}
+
+func renderHTMLSection(sb *strings.Builder, title string, relations []RelationCoverage) {
+	fmt.Fprintf(sb, "<h2>%s (%d)</h2>\n<table border=\"1\">\n<tr><th>Type</th><th>Relation</th></tr>\n", html.EscapeString(title), len(relations))
+	for _, rel := range relations {
+		fmt.Fprintf(sb, "<tr><td>%s</td><td>%s</td></tr>\n", html.EscapeString(rel.TypeName), html.EscapeString(rel.RelationName))
+	}
+	sb.WriteString("</table>\n")
+}
+
+// coberturaCoverage mirrors the subset of the Cobertura XML schema that coverage dashboards
+// (Codecov, Jenkins' Cobertura plugin) care about: a single package containing one class per
+// model type, with one method per relation.
+type coberturaCoverage struct {
+	XMLName  xml.Name          `xml:"coverage"`
+	LineRate float64           `xml:"line-rate,attr"`
+	Version  string            `xml:"version,attr"`
+	Packages coberturaPackages `xml:"packages"`
+}
+
+type coberturaPackages struct {
+	Packages []coberturaPackage `xml:"package"`
+}
+
+type coberturaPackage struct {
+	Name     string           `xml:"name,attr"`
+	LineRate float64          `xml:"line-rate,attr"`
+	Classes  coberturaClasses `xml:"classes"`
+}
+
+type coberturaClasses struct {
+	Classes []coberturaClass `xml:"class"`
+}
+
+type coberturaClass struct {
+	Name     string           `xml:"name,attr"`
+	Filename string           `xml:"filename,attr"`
+	LineRate float64          `xml:"line-rate,attr"`
+	Methods  coberturaMethods `xml:"methods"`
+}
+
+type coberturaMethods struct {
+	Methods []coberturaMethod `xml:"method"`
+}
+
+type coberturaMethod struct {
+	Name     string  `xml:"name,attr"`
+	LineRate float64 `xml:"line-rate,attr"`
+}
+
+// renderReportCobertura maps each (type, relation) pair to a <class>/<method> entry, with a
+// line-rate of 1.0 for fully tested relations, 0.5 for partially tested ones, and 0 for
+// untested ones, so existing coverage dashboards can ingest model coverage alongside code
+// coverage.
+func renderReportCobertura(report *CoverageReport) (string, error) {
+	byType := make(map[string][]coberturaMethod)
+	var typeOrder []string
+
+	addRelations := func(relations []RelationCoverage, lineRate float64) {
+		for _, rel := range relations {
+			if _, exists := byType[rel.TypeName]; !exists {
+				typeOrder = append(typeOrder, rel.TypeName)
+			}
+			byType[rel.TypeName] = append(byType[rel.TypeName], coberturaMethod{
+				Name:     rel.RelationName,
+				LineRate: lineRate,
+			})
+		}
+	}
+
+	addRelations(report.UntestedRelations, 0)
+	addRelations(report.PartiallyTested, 0.5)
+	addRelations(report.FullyTested, 1.0)
+
+	// typeOrder reflects first-seen order across three independently-sorted buckets, so it isn't
+	// itself guaranteed to be alphabetical; sort explicitly so output is reproducible regardless
+	// of which bucket a type's relations happen to fall into.
+	sort.Strings(typeOrder)
+
+	classes := make([]coberturaClass, 0, len(typeOrder))
+	for _, typeName := range typeOrder {
+		methods := byType[typeName]
+		sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+		classes = append(classes, coberturaClass{
+			Name:     typeName,
+			Filename: typeName,
+			LineRate: report.Summary.WeightedCoveragePercentage / 100,
+			Methods:  coberturaMethods{Methods: methods},
+		})
+	}
+
+	coverage := coberturaCoverage{
+		Version:  "1.9",
+		LineRate: report.Summary.CoveragePercentage / 100,
+		Packages: coberturaPackages{
+			Packages: []coberturaPackage{
+				{
+					Name:     "model",
+					LineRate: report.Summary.CoveragePercentage / 100,
+					Classes:  coberturaClasses{Classes: classes},
+				},
+			},
+		},
+	}
+
+	marshalled, err := xml.MarshalIndent(coverage, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return xml.Header + string(marshalled), nil
+}
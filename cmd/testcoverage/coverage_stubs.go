@@ -0,0 +1,182 @@
+package testcoverage
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+const generateStubsFlag = "generate-stubs"
+
+// stubTuple mirrors the `tuple` shape expected inside a `.fga.yaml` checkAssertions entry.
+type stubTuple struct {
+	User     string `json:"user"`
+	Relation string `json:"relation"`
+	Object   string `json:"object"`
+}
+
+// stubCheckAssertion mirrors a single checkAssertions entry.
+type stubCheckAssertion struct {
+	Tuple       stubTuple `json:"tuple"`
+	Expectation bool      `json:"expectation"`
+}
+
+// stubListObjectsRequest mirrors the `request` shape expected inside a `.fga.yaml`
+// listObjectsAssertions entry.
+type stubListObjectsRequest struct {
+	User     string `json:"user"`
+	Type     string `json:"type"`
+	Relation string `json:"relation"`
+}
+
+// stubListObjectsAssertion mirrors a single listObjectsAssertions entry.
+type stubListObjectsAssertion struct {
+	Request     stubListObjectsRequest `json:"request"`
+	Expectation []string               `json:"expectation"`
+}
+
+// assertionStubs is the YAML fragment written by --generate-stubs: proposed assertions ready to
+// be pasted into a `.fga.yaml` test file's `stages[].checkAssertions` / `listObjectsAssertions`.
+type assertionStubs struct {
+	CheckAssertions       []stubCheckAssertion       `json:"checkAssertions,omitempty"`
+	ListObjectsAssertions []stubListObjectsAssertion `json:"listObjectsAssertions,omitempty"`
+}
+
+// generateStubs builds proposed assertion stubs for every relation in report.UntestedRelations
+// and every relation in report.PartiallyTested that is missing a positive or negative case,
+// using synthesized object/user IDs drawn from the relation's type restrictions: one subject
+// per directly-related type restriction (`<type>:1`, or `<type>:1#<relation>` for a userset
+// restriction). A `<type>:*` wildcard restriction is checked with the concrete `<type>:1`
+// subject, so a relation permitting both `user` and `user:*` gets the same Check stubs twice;
+// no wildcard tuple is emitted. Positive and negative checkAssertions target different objects (`:1` vs
+// `:2`) so the two can be pasted into the same test file without contradicting each other, and
+// listObjectsAssertions are only generated for concrete subjects, since ListObjects requires one.
+func generateStubs(model *openfgav1.AuthorizationModel, report *CoverageReport) (string, error) {
+	restrictions := relationTypeRestrictions(model)
+
+	stubs := assertionStubs{
+		CheckAssertions:       []stubCheckAssertion{},
+		ListObjectsAssertions: []stubListObjectsAssertion{},
+	}
+
+	for _, rel := range report.UntestedRelations {
+		appendRelationStubs(&stubs, restrictions, rel, true, true)
+	}
+	for _, rel := range report.PartiallyTested {
+		appendRelationStubs(&stubs, restrictions, rel, !rel.HasPositiveTest, !rel.HasNegativeTest)
+	}
+
+	marshalled, err := yaml.Marshal(stubs)
+	if err != nil {
+		return "", err
+	}
+
+	return string(marshalled), nil
+}
+
+func appendRelationStubs(stubs *assertionStubs, restrictions map[string][]string, rel RelationCoverage, needPositive, needNegative bool) {
+	if !needPositive && !needNegative {
+		return
+	}
+
+	key := fmt.Sprintf("%s#%s", rel.TypeName, rel.RelationName)
+	subjects := restrictions[key]
+	if len(subjects) == 0 {
+		return
+	}
+
+	// The positive and negative stubs use distinct objects so that pasting both into the same
+	// test file never contradicts itself: positiveObject is the one the author is expected to
+	// grant `subject` access to (e.g. via a tuple they add alongside these stubs), while
+	// negativeObject is left ungranted on purpose.
+	positiveObject := fmt.Sprintf("%s:1", rel.TypeName)
+	negativeObject := fmt.Sprintf("%s:2", rel.TypeName)
+
+	for _, subject := range subjects {
+		// A typed public wildcard (`user:*`) is valid as the subject of a *tuple*, but OpenFGA
+		// rejects it as the `user` of a Check request, so substitute a concrete subject of the
+		// same type for the asserted Check subject; `user:*` is only ever meaningful as a seed
+		// tuple, not as something you Check against directly.
+		checkSubject := concreteCheckSubject(subject)
+
+		if needPositive {
+			stubs.CheckAssertions = append(stubs.CheckAssertions, stubCheckAssertion{
+				Tuple:       stubTuple{User: checkSubject, Relation: rel.RelationName, Object: positiveObject},
+				Expectation: true,
+			})
+		}
+		if needNegative {
+			stubs.CheckAssertions = append(stubs.CheckAssertions, stubCheckAssertion{
+				Tuple:       stubTuple{User: checkSubject, Relation: rel.RelationName, Object: negativeObject},
+				Expectation: false,
+			})
+		}
+
+		// ListObjects requires a concrete user; a userset (`group:1#member`) or wildcard
+		// (`user:*`) subject is not a valid ListObjects request user, so only stub it when the
+		// relation's type restrictions permit a concrete subject.
+		isConcrete, _, _ := classifySubject(subject)
+		if !isConcrete {
+			continue
+		}
+
+		expectation := []string{}
+		if needPositive {
+			expectation = []string{positiveObject}
+		}
+
+		stubs.ListObjectsAssertions = append(stubs.ListObjectsAssertions, stubListObjectsAssertion{
+			Request:     stubListObjectsRequest{User: subject, Type: rel.TypeName, Relation: rel.RelationName},
+			Expectation: expectation,
+		})
+	}
+}
+
+// relationTypeRestrictions maps "type#relation" to the list of synthesized subject IDs its
+// type restrictions permit, e.g. ["user:1", "group:1#member", "user:*"].
+func relationTypeRestrictions(model *openfgav1.AuthorizationModel) map[string][]string {
+	restrictions := make(map[string][]string)
+
+	for _, typeDef := range model.GetTypeDefinitions() {
+		typeName := typeDef.GetType()
+
+		for relationName, relMeta := range typeDef.GetMetadata().GetRelations() {
+			key := fmt.Sprintf("%s#%s", typeName, relationName)
+
+			for _, restriction := range relMeta.GetDirectlyRelatedUserTypes() {
+				restrictions[key] = append(restrictions[key], synthesizeSubject(restriction))
+			}
+		}
+	}
+
+	return restrictions
+}
+
+// concreteCheckSubject returns `subject` unchanged unless it's a typed public wildcard
+// (`<type>:*`), in which case it returns a concrete subject of the same type (`<type>:1`), since
+// `user:*` is not a valid Check request subject.
+func concreteCheckSubject(subject string) string {
+	_, _, isWildcard := classifySubject(subject)
+	if !isWildcard {
+		return subject
+	}
+
+	typeName, _, err := splitObject(subject)
+	if err != nil {
+		return subject
+	}
+
+	return fmt.Sprintf("%s:1", typeName)
+}
+
+func synthesizeSubject(restriction *openfgav1.RelationReference) string {
+	if restriction.GetWildcard() != nil {
+		return fmt.Sprintf("%s:*", restriction.GetType())
+	}
+	if relation := restriction.GetRelation(); relation != "" {
+		return fmt.Sprintf("%s:1#%s", restriction.GetType(), relation)
+	}
+	return fmt.Sprintf("%s:1", restriction.GetType())
+}
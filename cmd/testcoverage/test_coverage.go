@@ -3,82 +3,231 @@ package testcoverage
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/structpb"
 	"sigs.k8s.io/yaml"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	parser "github.com/openfga/language/pkg/go/transformer"
 
 	checktest "github.com/openfga/openfga/internal/test/check"
+	listobjectstest "github.com/openfga/openfga/internal/test/listobjects"
+	listuserstest "github.com/openfga/openfga/internal/test/listusers"
 )
 
 const (
-	modelFileFlag = "model-file"
-	testFileFlag  = "test-file"
+	modelFileFlag           = "model-file"
+	testFileFlag            = "test-file"
+	formatFlag              = "format"
+	minCoverageFlag         = "min-coverage"
+	minRelationCoverageFlag = "min-relation-coverage"
+	failOnFlag              = "fail-on"
+
+	formatJSON      = "json"
+	formatYAML      = "yaml"
+	formatHTML      = "html"
+	formatCobertura = "cobertura"
+
+	failOnUntested = "untested"
+	failOnPartial  = "partial"
 )
 
 func NewTestCoverageCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "test-coverage",
 		Short: "Analyze test coverage for authorization model relations",
-		Long: `Analyze test coverage for authorization model relations by comparing the model 
+		Long: `Analyze test coverage for authorization model relations by comparing the model
 with test assertions to identify:
   - Relations that are not tested at all
   - Relations that only have positive test cases (allowed=true)
   - Relations that only have negative test cases (allowed=false)
 
 This helps ensure comprehensive test coverage for your authorization models.`,
-		RunE:  runTestCoverage,
-		Args:  cobra.NoArgs,
+		RunE: runTestCoverage,
+		Args: cobra.NoArgs,
 	}
 
 	flags := cmd.Flags()
 	flags.String(modelFileFlag, "", "path to the model file (DSL format)")
 	flags.String(testFileFlag, "", "path to the test file (YAML format)")
+	flags.String(formatFlag, formatJSON, "output format, one of: json, yaml, html, cobertura")
+	flags.Float64(minCoverageFlag, 0, "minimum aggregate coverage percentage (fully-tested / total relations) required, or the command exits non-zero")
+	flags.Float64(minRelationCoverageFlag, 0, "minimum weighted relation coverage percentage required (partially-tested relations count as 0.5), or the command exits non-zero")
+	flags.String(failOnFlag, "", "exit non-zero if any relation is untested (untested), or untested or partially tested (partial), one of: untested, partial")
+	flags.String(generateStubsFlag, "", "path to write proposed checkAssertions/listObjectsAssertions stubs (YAML) for untested and partially tested relations")
 
 	_ = cmd.MarkFlagRequired(modelFileFlag)
 	_ = cmd.MarkFlagRequired(testFileFlag)
 
+	cmd.AddCommand(NewTestCoverageDiffCommand())
+
 	return cmd
 }
 
-// TestFile represents the structure of test YAML files
+// TestFile represents the structure of test YAML files.
 type TestFile struct {
 	Tests []struct {
 		Name   string `yaml:"name"`
 		Stages []struct {
-			Model                 string                 `yaml:"model"`
-			CheckAssertions       []*checktest.Assertion `yaml:"checkAssertions"`
-			ListObjectsAssertions []interface{}          `yaml:"listObjectsAssertions"`
-			ListUsersAssertions   []interface{}          `yaml:"listUsersAssertions"`
+			Model                 string                       `yaml:"model"`
+			Tuples                []*openfgav1.TupleKey        `yaml:"tuples"`
+			CheckAssertions       []*checktest.Assertion       `yaml:"checkAssertions"`
+			ListObjectsAssertions []*listobjectstest.Assertion `yaml:"listObjectsAssertions"`
+			ListUsersAssertions   []*listuserstest.Assertion   `yaml:"listUsersAssertions"`
 		} `yaml:"stages"`
 	} `yaml:"tests"`
 }
 
-// RelationCoverage tracks the coverage status of a relation
+// RelationCoverage tracks the coverage status of a relation.
 type RelationCoverage struct {
 	TypeName         string `json:"type"`
 	RelationName     string `json:"relation"`
-	TestedDirectly   bool   `json:"tested_directly"`
-	TestedIndirectly bool   `json:"tested_indirectly"`
-	HasPositiveTest  bool   `json:"has_positive_test"`
+	TestedDirectly bool `json:"tested_directly"`
+
+	// TestedIndirectly is set when this relation was reached through a ComputedUserset or
+	// TupleToUserset edge that the branch tracer actually traversed while resolving another
+	// relation's assertion (see buildCoveredDependencyGraph), not merely because the rewrite
+	// graph could structurally reach it. See CoverageReport.Branches for the full path-sensitive
+	// view of exactly which rewrite arms were exercised.
+	TestedIndirectly bool `json:"tested_indirectly"`
+	HasPositiveTest  bool `json:"has_positive_test"`
 	HasNegativeTest  bool   `json:"has_negative_test"`
+
+	// TestedByCheck, TestedByListObjects and TestedByListUsers track which API surface(s)
+	// exercised this relation. Check, ListObjects and ListUsers can diverge (e.g. due to
+	// contextual tuples or conditions), so a relation only covered by one of the three is
+	// surfaced via CoverageReport.SingleSurfaceTested as a gap worth filling in.
+	TestedByCheck       bool `json:"tested_by_check"`
+	TestedByListObjects bool `json:"tested_by_list_objects"`
+	TestedByListUsers   bool `json:"tested_by_list_users"`
+
+	// HasConcreteSubjectTest and HasUsersetSubjectTest record the shape of the subject an
+	// assertion was run against, mirroring the distinction SpiceDB draws between a regular
+	// subject and a public wildcard: a concrete `user:anne` and a userset subject like
+	// `group:eng#member` are resolved differently, so covering one shape says nothing about
+	// the other.
+	HasConcreteSubjectTest bool `json:"has_concrete_subject_test"`
+	HasUsersetSubjectTest  bool `json:"has_userset_subject_test"`
+
+	// HasWildcardSubjectTest is true when a test stage seeds a `<type>:*` wildcard tuple for
+	// this relation *and* the relation has a concrete-subject assertion against it, which is
+	// what actually exercises wildcard resolution: `user:*` is never a valid Check/ListObjects
+	// request subject, so it can't be detected from the asserted subject's own shape.
+	HasWildcardSubjectTest bool `json:"has_wildcard_subject_test"`
+
+	// AllowsWildcardSubject is true when the relation's type restrictions permit a
+	// `<type>:*` public wildcard subject, regardless of whether it was ever asserted against.
+	AllowsWildcardSubject bool `json:"allows_wildcard_subject"`
+
+	// RequiredConditions lists the ABAC conditions that at least one of this relation's
+	// directly-related-user-type restrictions requires (e.g. `[user with in_region]`).
+	RequiredConditions []string `json:"required_conditions,omitempty"`
+
+	// HasContextAssertion is true when at least one Check assertion against this relation
+	// supplied a non-empty Context, which is what's needed to actually evaluate a condition.
+	// ListObjects and ListUsers assertions are not inspected for Context.
+	HasContextAssertion bool `json:"has_context_assertion"`
+
+	// ConditionRequiredWithoutContext flags a relation that requires a condition but whose
+	// Check assertions never supplied Context, meaning the condition logic itself was never
+	// exercised even though the relation otherwise looks tested.
+	ConditionRequiredWithoutContext bool `json:"condition_required_without_context"`
 }
 
-// CoverageReport represents the complete coverage analysis
+// CoverageReport represents the complete coverage analysis.
 type CoverageReport struct {
 	UntestedRelations []RelationCoverage `json:"untested_relations"`
 	PartiallyTested   []RelationCoverage `json:"partially_tested"`
 	FullyTested       []RelationCoverage `json:"fully_tested"`
+
+	// MissingWildcardCoverage lists relations whose type restrictions permit a public
+	// wildcard subject (`user:*`) but whose wildcard grant was never exercised (see
+	// HasWildcardSubjectTest). Wildcard
+	// resolution is a common source of authorization bugs, so this gap is surfaced even when
+	// the relation otherwise looks fully tested.
+	MissingWildcardCoverage []RelationCoverage `json:"missing_wildcard_coverage"`
+
+	// SingleSurfaceTested lists directly tested relations that were only ever exercised through
+	// one of Check/ListObjects/ListUsers, whether or not they have both a positive and a
+	// negative case. Check, ListObjects and ListUsers can legitimately disagree on a relation's
+	// results, so this is surfaced as a gap to fill in rather than folded into FullyTested or
+	// CoveragePercentage, since most relations are (correctly) only ever exercised via Check.
+	SingleSurfaceTested []RelationCoverage `json:"single_surface_tested"`
+
+	// Conditions reports coverage of the model's ABAC conditions: which ones are declared,
+	// which are never evaluated by any assertion, and a per-(type, relation, condition)
+	// breakdown.
+	Conditions ConditionCoverage `json:"conditions"`
+
+	// Summary aggregates the report into the figures used for CI threshold gating.
+	Summary CoverageSummary `json:"summary"`
+
+	// Branches reports path-sensitive coverage of each relation's rewrite tree: which
+	// This/ComputedUserset/TupleToUserset/Union/Intersection/Difference nodes were actually
+	// traversed while resolving an assertion, as opposed to merely being reachable from a
+	// tested relation.
+	Branches BranchCoverage `json:"branches"`
+}
+
+// CoverageSummary aggregates a CoverageReport into the percentages used to gate CI, so callers
+// don't have to recompute them from the relation buckets.
+type CoverageSummary struct {
+	TotalRelations       int `json:"total_relations"`
+	FullyTestedCount     int `json:"fully_tested_count"`
+	PartiallyTestedCount int `json:"partially_tested_count"`
+	UntestedCount        int `json:"untested_count"`
+
+	// CoveragePercentage is fully-tested relations over total relations.
+	CoveragePercentage float64 `json:"coverage_percentage"`
+
+	// WeightedCoveragePercentage additionally counts partially-tested relations as half
+	// covered, so it rewards progress on a relation before it reaches full coverage.
+	WeightedCoveragePercentage float64 `json:"weighted_coverage_percentage"`
+}
+
+func (s CoverageSummary) meetsThresholds(minCoverage, minRelationCoverage float64) bool {
+	return s.CoveragePercentage >= minCoverage && s.WeightedCoveragePercentage >= minRelationCoverage
+}
+
+// ConditionUsage records whether a specific condition, as required by a relation's type
+// restriction, was ever exercised by a Context-bearing Check assertion.
+type ConditionUsage struct {
+	TypeName      string `json:"type"`
+	RelationName  string `json:"relation"`
+	ConditionName string `json:"condition"`
+	Evaluated     bool   `json:"evaluated"`
+
+	// subjectTypes lists the directly-related-user types (e.g. "user", "group") whose
+	// restriction actually carries this condition, so markConditionEvaluated can require the
+	// asserted subject's type to match rather than crediting any context-bearing assertion
+	// against the relation.
+	subjectTypes []string
+}
+
+// ConditionCoverage summarizes coverage of the model's ABAC conditions.
+type ConditionCoverage struct {
+	DeclaredConditions    []string         `json:"declared_conditions"`
+	UnevaluatedConditions []string         `json:"unevaluated_conditions"`
+	RelationConditions    []ConditionUsage `json:"relation_conditions"`
 }
 
 func runTestCoverage(cmd *cobra.Command, _ []string) error {
 	modelFile, _ := cmd.Flags().GetString(modelFileFlag)
 	testFile, _ := cmd.Flags().GetString(testFileFlag)
+	format, _ := cmd.Flags().GetString(formatFlag)
+	minCoverage, _ := cmd.Flags().GetFloat64(minCoverageFlag)
+	minRelationCoverage, _ := cmd.Flags().GetFloat64(minRelationCoverageFlag)
+	failOn, _ := cmd.Flags().GetString(failOnFlag)
+	generateStubsPath, _ := cmd.Flags().GetString(generateStubsFlag)
+
+	if err := validateFailOn(failOn); err != nil {
+		return err
+	}
 
 	// Read model file
 	modelContent, err := os.ReadFile(modelFile)
@@ -112,15 +261,45 @@ func runTestCoverage(cmd *cobra.Command, _ []string) error {
 	}
 
 	// Output report
-	marshalled, err := json.MarshalIndent(report, "", "  ")
+	rendered, err := renderReport(report, format)
 	if err != nil {
 		return fmt.Errorf("error generating report: %w", err)
 	}
-	fmt.Println(string(marshalled))
+	fmt.Println(rendered)
+
+	if generateStubsPath != "" {
+		stubs, err := generateStubs(model, report)
+		if err != nil {
+			return fmt.Errorf("failed to generate assertion stubs: %w", err)
+		}
+		if err := os.WriteFile(generateStubsPath, []byte(stubs), 0o644); err != nil {
+			return fmt.Errorf("failed to write assertion stubs: %w", err)
+		}
+	}
+
+	if failOn == failOnUntested && report.Summary.UntestedCount > 0 {
+		return fmt.Errorf("%d relation(s) are untested", report.Summary.UntestedCount)
+	}
+	if failOn == failOnPartial && (report.Summary.UntestedCount > 0 || report.Summary.PartiallyTestedCount > 0) {
+		return fmt.Errorf("%d relation(s) are untested or partially tested", report.Summary.UntestedCount+report.Summary.PartiallyTestedCount)
+	}
+	if !report.Summary.meetsThresholds(minCoverage, minRelationCoverage) {
+		return fmt.Errorf("coverage %.2f%% (weighted %.2f%%) does not meet the required minimums of %.2f%% (weighted %.2f%%)",
+			report.Summary.CoveragePercentage, report.Summary.WeightedCoveragePercentage, minCoverage, minRelationCoverage)
+	}
 
 	return nil
 }
 
+func validateFailOn(failOn string) error {
+	switch failOn {
+	case "", failOnUntested, failOnPartial:
+		return nil
+	default:
+		return fmt.Errorf("invalid --%s value %q: must be one of %q, %q", failOnFlag, failOn, failOnUntested, failOnPartial)
+	}
+}
+
 func analyzeCoverage(model *openfgav1.AuthorizationModel, testData *TestFile) (*CoverageReport, error) {
 	ctx := context.Background()
 
@@ -133,53 +312,55 @@ func analyzeCoverage(model *openfgav1.AuthorizationModel, testData *TestFile) (*
 		coverage[key] = allRelations[key]
 	}
 
+	markWildcardEligibility(model, coverage)
+	conditionUsage := buildConditionUsage(model, coverage)
+
 	// Analyze test assertions
 	for _, test := range testData.Tests {
 		for _, stage := range test.Stages {
 			for _, assertion := range stage.CheckAssertions {
-				if assertion.Tuple == nil {
-					continue
-				}
-
-				objectType, err := getObjectType(assertion.Tuple.GetObject())
-				if err != nil {
-					continue
-				}
-
-				relation := assertion.Tuple.GetRelation()
-				key := fmt.Sprintf("%s#%s", objectType, relation)
-
-				if cov, exists := coverage[key]; exists {
-					cov.TestedDirectly = true
-					if assertion.Expectation {
-						cov.HasPositiveTest = true
-					} else {
-						cov.HasNegativeTest = true
-					}
-				}
+				markCheckAssertion(coverage, conditionUsage, assertion)
+			}
+			for _, assertion := range stage.ListObjectsAssertions {
+				markListObjectsAssertion(coverage, assertion)
+			}
+			for _, assertion := range stage.ListUsersAssertions {
+				markListUsersAssertion(coverage, assertion)
 			}
 		}
 	}
 
-	// Build computed relation graph to track indirect testing
-	computedGraph := buildComputedGraph(model)
+	markWildcardTupleCoverage(coverage, testData)
 
-	// Propagate indirect testing information
+	// Trace every assertion against the model's rewrite trees once, and reuse the result both
+	// for the path-sensitive dependency graph below and for report.Branches, so the two never
+	// disagree about which branches were actually exercised.
+	trees, tracer := traceModel(model, testData)
+
+	// Propagate indirect testing information, but only through rewrite-tree edges the tracer
+	// actually traversed, not every relation a Union/Intersection/Difference could structurally
+	// reach.
+	coveredGraph := buildCoveredDependencyGraph(trees, tracer)
 	for key, cov := range coverage {
 		if cov.TestedDirectly {
-			// Mark all relations this one depends on as indirectly tested
-			markIndirectlyTested(key, coverage, computedGraph)
+			markIndirectlyTested(key, coverage, coveredGraph)
 		}
 	}
 
 	// Generate report
 	report := &CoverageReport{
-		UntestedRelations: []RelationCoverage{},
-		PartiallyTested:   []RelationCoverage{},
-		FullyTested:       []RelationCoverage{},
+		UntestedRelations:       []RelationCoverage{},
+		PartiallyTested:         []RelationCoverage{},
+		FullyTested:             []RelationCoverage{},
+		MissingWildcardCoverage: []RelationCoverage{},
+		SingleSurfaceTested:     []RelationCoverage{},
 	}
 
 	for _, cov := range coverage {
+		if len(cov.RequiredConditions) > 0 && cov.TestedDirectly && !cov.HasContextAssertion {
+			cov.ConditionRequiredWithoutContext = true
+		}
+
 		if !cov.TestedDirectly && !cov.TestedIndirectly {
 			report.UntestedRelations = append(report.UntestedRelations, *cov)
 		} else if !cov.HasPositiveTest || !cov.HasNegativeTest {
@@ -187,87 +368,395 @@ func analyzeCoverage(model *openfgav1.AuthorizationModel, testData *TestFile) (*
 		} else {
 			report.FullyTested = append(report.FullyTested, *cov)
 		}
+
+		if cov.AllowsWildcardSubject && !cov.HasWildcardSubjectTest {
+			report.MissingWildcardCoverage = append(report.MissingWildcardCoverage, *cov)
+		}
+
+		if !testedByAllSurfacesRelevant(cov) {
+			report.SingleSurfaceTested = append(report.SingleSurfaceTested, *cov)
+		}
 	}
 
+	// coverage is keyed by map, so every bucket above was appended to in random order; sort each
+	// one by (type, relation) so JSON/YAML/Cobertura output is reproducible across runs.
+	sortRelationCoverage(report.UntestedRelations)
+	sortRelationCoverage(report.PartiallyTested)
+	sortRelationCoverage(report.FullyTested)
+	sortRelationCoverage(report.MissingWildcardCoverage)
+	sortRelationCoverage(report.SingleSurfaceTested)
+
+	report.Conditions = buildConditionCoverage(model, conditionUsage)
+	report.Summary = buildCoverageSummary(report)
+	report.Branches = analyzeBranchCoverage(model, trees, tracer)
+
 	_ = ctx // silence unused warning
 
 	return report, nil
 }
 
-func extractAllRelations(model *openfgav1.AuthorizationModel) map[string]*RelationCoverage {
-	relations := make(map[string]*RelationCoverage)
+// sortRelationCoverage sorts a bucket of RelationCoverage in place by (type, relation), since the
+// buckets are built by ranging over a map and would otherwise be in random order.
+func sortRelationCoverage(relations []RelationCoverage) {
+	sort.Slice(relations, func(i, j int) bool {
+		if relations[i].TypeName != relations[j].TypeName {
+			return relations[i].TypeName < relations[j].TypeName
+		}
+		return relations[i].RelationName < relations[j].RelationName
+	})
+}
+
+// buildCoverageSummary computes the aggregate coverage percentages used for CI threshold
+// gating from the relation buckets already populated on the report.
+func buildCoverageSummary(report *CoverageReport) CoverageSummary {
+	summary := CoverageSummary{
+		FullyTestedCount:     len(report.FullyTested),
+		PartiallyTestedCount: len(report.PartiallyTested),
+		UntestedCount:        len(report.UntestedRelations),
+	}
+	summary.TotalRelations = summary.FullyTestedCount + summary.PartiallyTestedCount + summary.UntestedCount
+
+	if summary.TotalRelations == 0 {
+		return summary
+	}
+
+	total := float64(summary.TotalRelations)
+	summary.CoveragePercentage = float64(summary.FullyTestedCount) / total * 100
+	summary.WeightedCoveragePercentage = (float64(summary.FullyTestedCount) + 0.5*float64(summary.PartiallyTestedCount)) / total * 100
+
+	return summary
+}
 
+// markWildcardEligibility records, for every relation, whether its type restrictions permit a
+// public wildcard subject (e.g. `[user:*]` in the DSL), by inspecting the directly-related-user
+// type restrictions in the model's metadata.
+func markWildcardEligibility(model *openfgav1.AuthorizationModel, coverage map[string]*RelationCoverage) {
 	for _, typeDef := range model.GetTypeDefinitions() {
 		typeName := typeDef.GetType()
 
-		for relationName := range typeDef.GetRelations() {
+		for relationName, relMeta := range typeDef.GetMetadata().GetRelations() {
 			key := fmt.Sprintf("%s#%s", typeName, relationName)
-			relations[key] = &RelationCoverage{
-				TypeName:     typeName,
-				RelationName: relationName,
+			cov, exists := coverage[key]
+			if !exists {
+				continue
+			}
+
+			for _, restriction := range relMeta.GetDirectlyRelatedUserTypes() {
+				if restriction.GetWildcard() != nil {
+					cov.AllowsWildcardSubject = true
+					break
+				}
 			}
 		}
 	}
+}
 
-	return relations
+// conditionUsageKey identifies a single (type, relation, condition) triple.
+func conditionUsageKey(typeName, relationName, conditionName string) string {
+	return fmt.Sprintf("%s#%s#%s", typeName, relationName, conditionName)
 }
 
-func buildComputedGraph(model *openfgav1.AuthorizationModel) map[string][]string {
-	graph := make(map[string][]string)
+// buildConditionUsage walks the directly-related-user-type restrictions of every relation,
+// records which conditions each relation requires, and seeds a per-(type, relation, condition)
+// usage map that assertion processing fills in as conditions are actually evaluated.
+func buildConditionUsage(model *openfgav1.AuthorizationModel, coverage map[string]*RelationCoverage) map[string]*ConditionUsage {
+	usage := make(map[string]*ConditionUsage)
 
 	for _, typeDef := range model.GetTypeDefinitions() {
 		typeName := typeDef.GetType()
 
-		for relationName, rewrite := range typeDef.GetRelations() {
+		for relationName, relMeta := range typeDef.GetMetadata().GetRelations() {
 			key := fmt.Sprintf("%s#%s", typeName, relationName)
+			cov, exists := coverage[key]
+			if !exists {
+				continue
+			}
+
+			for _, restriction := range relMeta.GetDirectlyRelatedUserTypes() {
+				conditionName := restriction.GetCondition()
+				if conditionName == "" {
+					continue
+				}
 
-			// Find dependencies in the relation rewrite
-			deps := extractDependencies(typeName, rewrite)
-			graph[key] = deps
+				cov.RequiredConditions = append(cov.RequiredConditions, conditionName)
+				key := conditionUsageKey(typeName, relationName, conditionName)
+				if _, exists := usage[key]; !exists {
+					usage[key] = &ConditionUsage{
+						TypeName:      typeName,
+						RelationName:  relationName,
+						ConditionName: conditionName,
+					}
+				}
+				usage[key].subjectTypes = append(usage[key].subjectTypes, restriction.GetType())
+			}
 		}
 	}
 
-	return graph
+	return usage
 }
 
-func extractDependencies(typeName string, rewrite *openfgav1.Userset) []string {
-	if rewrite == nil {
-		return nil
+// markConditionEvaluated records that an assertion with a non-empty Context exercised the
+// condition required for `subjectUser` on (typeName, relationName), if any. A condition is only
+// credited when `subjectUser`'s type matches one of the directly-related-user types that actually
+// carries it, since a relation can mix conditioned and unconditioned (or differently conditioned)
+// restrictions across its allowed subject types, e.g. `viewer: [user, user with cond_a, group#member with cond_b]`.
+func markConditionEvaluated(coverage map[string]*RelationCoverage, conditionUsage map[string]*ConditionUsage, typeName, relationName, subjectUser string, hasContext bool) {
+	key := fmt.Sprintf("%s#%s", typeName, relationName)
+	cov, exists := coverage[key]
+	if !exists {
+		return
+	}
+
+	if hasContext {
+		cov.HasContextAssertion = true
 	}
 
-	var deps []string
+	if !hasContext {
+		return
+	}
 
-	switch r := rewrite.Userset.(type) {
-	case *openfgav1.Userset_This:
-		// Direct assignment, no dependencies
-		return nil
-	case *openfgav1.Userset_ComputedUserset:
-		// References another relation on same type
-		relation := r.ComputedUserset.GetRelation()
-		deps = append(deps, fmt.Sprintf("%s#%s", typeName, relation))
-	case *openfgav1.Userset_TupleToUserset:
-		// TTU relationship
-		computedRelation := r.TupleToUserset.GetComputedUserset().GetRelation()
-		deps = append(deps, fmt.Sprintf("%s#%s", typeName, computedRelation))
-	case *openfgav1.Userset_Union:
-		// Union of relations
-		for _, child := range r.Union.GetChild() {
-			deps = append(deps, extractDependencies(typeName, child)...)
+	subjectType, err := getObjectType(subjectUser)
+	if err != nil || subjectType == "" {
+		return
+	}
+
+	for _, conditionName := range cov.RequiredConditions {
+		usage, exists := conditionUsage[conditionUsageKey(typeName, relationName, conditionName)]
+		if !exists {
+			continue
+		}
+		for _, allowedType := range usage.subjectTypes {
+			if allowedType == subjectType {
+				usage.Evaluated = true
+				break
+			}
+		}
+	}
+}
+
+// buildConditionCoverage renders the final ConditionCoverage section from the model's declared
+// conditions and the per-relation usage map accumulated while processing assertions. Every slice
+// is sorted so the report (and any baseline diff) is stable across runs, since both
+// model.GetConditions() and the usage map iterate in random order.
+func buildConditionCoverage(model *openfgav1.AuthorizationModel, conditionUsage map[string]*ConditionUsage) ConditionCoverage {
+	report := ConditionCoverage{
+		DeclaredConditions:    []string{},
+		UnevaluatedConditions: []string{},
+		RelationConditions:    []ConditionUsage{},
+	}
+
+	for name := range model.GetConditions() {
+		report.DeclaredConditions = append(report.DeclaredConditions, name)
+	}
+	sort.Strings(report.DeclaredConditions)
+
+	evaluatedConditions := make(map[string]bool)
+	for _, usage := range conditionUsage {
+		report.RelationConditions = append(report.RelationConditions, *usage)
+		if usage.Evaluated {
+			evaluatedConditions[usage.ConditionName] = true
+		}
+	}
+	sort.Slice(report.RelationConditions, func(i, j int) bool {
+		a, b := report.RelationConditions[i], report.RelationConditions[j]
+		if a.TypeName != b.TypeName {
+			return a.TypeName < b.TypeName
+		}
+		if a.RelationName != b.RelationName {
+			return a.RelationName < b.RelationName
+		}
+		return a.ConditionName < b.ConditionName
+	})
+
+	for _, name := range report.DeclaredConditions {
+		if !evaluatedConditions[name] {
+			report.UnevaluatedConditions = append(report.UnevaluatedConditions, name)
+		}
+	}
+
+	return report
+}
+
+// classifySubject inspects a subject identifier (the `user` side of a tuple, in the form
+// `type:id`, `type:id#relation` or `type:*`) and reports which of the three coverage buckets
+// it falls into: a concrete user, a userset subject, or a public wildcard.
+func classifySubject(user string) (isConcrete, isUserset, isWildcard bool) {
+	if user == "" {
+		return false, false, false
+	}
+	if strings.HasSuffix(user, ":*") {
+		return false, false, true
+	}
+	if strings.Contains(user, "#") {
+		return false, true, false
+	}
+	return true, false, false
+}
+
+func markSubjectCoverage(cov *RelationCoverage, user string) {
+	isConcrete, isUserset, _ := classifySubject(user)
+	if isConcrete {
+		cov.HasConcreteSubjectTest = true
+	}
+	if isUserset {
+		cov.HasUsersetSubjectTest = true
+	}
+}
+
+// markWildcardTupleCoverage records that a relation's public wildcard grant was actually
+// exercised by the test suite: a stage seeds a `<type>:*` tuple for the relation, and the
+// relation also has a concrete-subject assertion against it. `user:*` is never a valid
+// Check/ListObjects request subject, so wildcard coverage can only be observed from the seed
+// tuple side, not from the shape of any asserted subject.
+func markWildcardTupleCoverage(coverage map[string]*RelationCoverage, testData *TestFile) {
+	for _, test := range testData.Tests {
+		for _, stage := range test.Stages {
+			for _, t := range stage.Tuples {
+				if t == nil || !strings.HasSuffix(t.GetUser(), ":*") {
+					continue
+				}
+
+				objectType, err := getObjectType(t.GetObject())
+				if err != nil {
+					continue
+				}
+
+				key := fmt.Sprintf("%s#%s", objectType, t.GetRelation())
+				cov, exists := coverage[key]
+				if exists && cov.HasConcreteSubjectTest {
+					cov.HasWildcardSubjectTest = true
+				}
+			}
 		}
-	case *openfgav1.Userset_Intersection:
-		// Intersection of relations
-		for _, child := range r.Intersection.GetChild() {
-			deps = append(deps, extractDependencies(typeName, child)...)
+	}
+}
+
+// testedByAllSurfacesRelevant reports whether a relation that has been directly tested was
+// exercised by more than a single API surface. A relation tested only via Check (and never via
+// ListObjects or ListUsers, or vice versa) is reported in CoverageReport.SingleSurfaceTested,
+// since Check, ListObjects and ListUsers can legitimately disagree on a relation's results — but
+// it otherwise still counts toward FullyTested/CoveragePercentage, since most relations are only
+// ever meant to be exercised through a single surface.
+func testedByAllSurfacesRelevant(cov *RelationCoverage) bool {
+	if !cov.TestedDirectly {
+		return true
+	}
+	surfaces := 0
+	if cov.TestedByCheck {
+		surfaces++
+	}
+	if cov.TestedByListObjects {
+		surfaces++
+	}
+	if cov.TestedByListUsers {
+		surfaces++
+	}
+	return surfaces > 1
+}
+
+func markCheckAssertion(coverage map[string]*RelationCoverage, conditionUsage map[string]*ConditionUsage, assertion *checktest.Assertion) {
+	if assertion == nil || assertion.Tuple == nil {
+		return
+	}
+
+	objectType, err := getObjectType(assertion.Tuple.GetObject())
+	if err != nil {
+		return
+	}
+
+	relation := assertion.Tuple.GetRelation()
+	key := fmt.Sprintf("%s#%s", objectType, relation)
+
+	cov, exists := coverage[key]
+	if !exists {
+		return
+	}
+
+	cov.TestedDirectly = true
+	cov.TestedByCheck = true
+	if assertion.Expectation {
+		cov.HasPositiveTest = true
+	} else {
+		cov.HasNegativeTest = true
+	}
+	markSubjectCoverage(cov, assertion.Tuple.GetUser())
+	markConditionEvaluated(coverage, conditionUsage, objectType, relation, assertion.Tuple.GetUser(), contextIsNonEmpty(assertion.Context))
+}
+
+// contextIsNonEmpty reports whether an assertion supplied any contextual values, which is
+// what's required to actually evaluate an ABAC condition.
+func contextIsNonEmpty(context *structpb.Struct) bool {
+	return context != nil && len(context.GetFields()) > 0
+}
+
+func markListObjectsAssertion(coverage map[string]*RelationCoverage, assertion *listobjectstest.Assertion) {
+	if assertion == nil || assertion.Request == nil {
+		return
+	}
+
+	key := fmt.Sprintf("%s#%s", assertion.Request.GetType(), assertion.Request.GetRelation())
+
+	cov, exists := coverage[key]
+	if !exists {
+		return
+	}
+
+	cov.TestedDirectly = true
+	cov.TestedByListObjects = true
+	if len(assertion.Expectation) > 0 {
+		cov.HasPositiveTest = true
+	} else {
+		cov.HasNegativeTest = true
+	}
+	markSubjectCoverage(cov, assertion.Request.GetUser())
+}
+
+func markListUsersAssertion(coverage map[string]*RelationCoverage, assertion *listuserstest.Assertion) {
+	if assertion == nil || assertion.Request == nil {
+		return
+	}
+
+	objectType, err := getObjectType(assertion.Request.GetObject())
+	if err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("%s#%s", objectType, assertion.Request.GetRelation())
+
+	cov, exists := coverage[key]
+	if !exists {
+		return
+	}
+
+	cov.TestedDirectly = true
+	cov.TestedByListUsers = true
+	if assertion.Expectation != nil && len(assertion.Expectation.Users) > 0 {
+		cov.HasPositiveTest = true
+	} else {
+		cov.HasNegativeTest = true
+	}
+}
+
+func extractAllRelations(model *openfgav1.AuthorizationModel) map[string]*RelationCoverage {
+	relations := make(map[string]*RelationCoverage)
+
+	for _, typeDef := range model.GetTypeDefinitions() {
+		typeName := typeDef.GetType()
+
+		for relationName := range typeDef.GetRelations() {
+			key := fmt.Sprintf("%s#%s", typeName, relationName)
+			relations[key] = &RelationCoverage{
+				TypeName:     typeName,
+				RelationName: relationName,
+			}
 		}
-	case *openfgav1.Userset_Difference:
-		// Difference (but not)
-		deps = append(deps, extractDependencies(typeName, r.Difference.GetBase())...)
-		deps = append(deps, extractDependencies(typeName, r.Difference.GetSubtract())...)
 	}
 
-	return deps
+	return relations
 }
 
+// markIndirectlyTested walks `graph` (see buildCoveredDependencyGraph) from `relation`, marking
+// every relation reachable through a covered rewrite-tree edge as indirectly tested.
 func markIndirectlyTested(relation string, coverage map[string]*RelationCoverage, graph map[string][]string) {
 	visited := make(map[string]bool)
 	var visit func(string)